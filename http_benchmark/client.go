@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// requestOutcome is what any engine reports back for a single request,
+// independent of which HTTP stack served it. body is only populated when
+// the caller asks for it (captureBody), since scenario steps need it for
+// variable extraction but a plain benchmark run doesn't.
+type requestOutcome struct {
+	status    int
+	bodyBytes int
+	body      []byte
+	latency   time.Duration
+}
+
+// engineClient is the pluggable seam between the requester's worker loop
+// and the underlying HTTP stack, selected with -engine=net|fasthttp.
+type engineClient interface {
+	Do(ctx context.Context, method, url string, headers []header, body string, captureBody bool) (requestOutcome, error)
+	Close()
+}
+
+// byteCounters tallies bytes actually seen on the wire, wired into the
+// dialer of whichever engine is active. This is what lets the live
+// progress display and final report show real throughput instead of
+// Content-Length, which chunked responses don't send.
+type byteCounters struct {
+	in  int64
+	out int64
+}
+
+func (c *byteCounters) snapshot() (in, out int64) {
+	return atomic.LoadInt64(&c.in), atomic.LoadInt64(&c.out)
+}
+
+// countingConn wraps a net.Conn so every byte read or written is tallied
+// into a shared byteCounters, regardless of how the HTTP stack above it
+// frames requests and responses.
+type countingConn struct {
+	net.Conn
+	counters *byteCounters
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.counters.in, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.counters.out, int64(n))
+	return n, err
+}
+
+// netEngineClient is the default engine, built on net/http.
+type netEngineClient struct {
+	client *http.Client
+}
+
+func newNetEngineClient(concurrentRequests int, counters *byteCounters) *netEngineClient {
+	dialer := &net.Dialer{}
+	tr := &http.Transport{
+		MaxIdleConns:      100,
+		MaxConnsPerHost:   concurrentRequests,
+		IdleConnTimeout:   60 * time.Second,
+		DisableKeepAlives: false,
+		WriteBufferSize:   8 * 1024, // Reduce buffer size not to exhaust memory
+		ReadBufferSize:    8 * 1024,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &countingConn{Conn: conn, counters: counters}, nil
+		},
+	}
+	return &netEngineClient{client: &http.Client{Transport: tr}}
+}
+
+func (e *netEngineClient) Do(ctx context.Context, method, url string, headers []header, body string, captureBody bool) (requestOutcome, error) {
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), url, strings.NewReader(body))
+	if err != nil {
+		return requestOutcome{}, err
+	}
+	for _, h := range headers {
+		req.Header.Add(h.key, h.value)
+	}
+
+	start := time.Now()
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return requestOutcome{}, err
+	}
+	defer resp.Body.Close()
+
+	var respBody []byte
+	var bodyBytes int64
+	if captureBody {
+		respBody, err = io.ReadAll(resp.Body)
+		bodyBytes = int64(len(respBody))
+	} else {
+		bodyBytes, err = io.Copy(io.Discard, resp.Body) // reads the entire body to EOF, clearing the TCP stream.
+	}
+	latency := time.Since(start)
+	if err != nil {
+		return requestOutcome{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return requestOutcome{}, errors.New(strconv.Itoa(resp.StatusCode))
+	}
+	return requestOutcome{status: resp.StatusCode, bodyBytes: int(bodyBytes), body: respBody, latency: latency}, nil
+}
+
+func (e *netEngineClient) Close() {
+	e.client.CloseIdleConnections()
+}
+
+// fasthttpEngineClient selects github.com/valyala/fasthttp for its
+// low-allocation hot path instead of net/http.
+type fasthttpEngineClient struct {
+	client *fasthttp.Client
+}
+
+func newFasthttpEngineClient(concurrentRequests int, counters *byteCounters) *fasthttpEngineClient {
+	client := &fasthttp.Client{
+		MaxConnsPerHost: concurrentRequests,
+		Dial: func(addr string) (net.Conn, error) {
+			conn, err := fasthttp.Dial(addr)
+			if err != nil {
+				return nil, err
+			}
+			return &countingConn{Conn: conn, counters: counters}, nil
+		},
+	}
+	return &fasthttpEngineClient{client: client}
+}
+
+func (e *fasthttpEngineClient) Do(ctx context.Context, method, url string, headers []header, body string, captureBody bool) (requestOutcome, error) {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+
+	req.SetRequestURI(url)
+	req.Header.SetMethod(strings.ToUpper(method))
+	for _, h := range headers {
+		req.Header.Set(h.key, h.value)
+	}
+	if body != "" {
+		req.SetBodyString(body)
+	}
+
+	start := time.Now()
+
+	// fasthttp.Client has no context support of its own, so DoDeadline only
+	// ever bounds requests that already have a -duration deadline. Race it
+	// against ctx.Done() too, or Ctrl-C (no deadline set) would block here
+	// for the life of the request instead of honoring graceful shutdown.
+	done := make(chan error, 1)
+	go func() {
+		if deadline, ok := ctx.Deadline(); ok {
+			done <- e.client.DoDeadline(req, resp, deadline)
+		} else {
+			done <- e.client.Do(req, resp)
+		}
+	}()
+
+	select {
+	case err := <-done:
+		defer fasthttp.ReleaseRequest(req)
+		defer fasthttp.ReleaseResponse(resp)
+		latency := time.Since(start)
+		if err != nil {
+			return requestOutcome{}, err
+		}
+		if resp.StatusCode() != fasthttp.StatusOK {
+			return requestOutcome{}, errors.New(strconv.Itoa(resp.StatusCode()))
+		}
+		outcome := requestOutcome{status: resp.StatusCode(), bodyBytes: len(resp.Body()), latency: latency}
+		if captureBody {
+			outcome.body = append([]byte(nil), resp.Body()...) // copy out before ReleaseResponse reuses the buffer
+		}
+		return outcome, nil
+	case <-ctx.Done():
+		// The request is still in flight below us; wait for it in the
+		// background before releasing req/resp back to fasthttp's pools,
+		// since releasing them while still in use would race.
+		go func() {
+			<-done
+			fasthttp.ReleaseRequest(req)
+			fasthttp.ReleaseResponse(resp)
+		}()
+		return requestOutcome{}, ctx.Err()
+	}
+}
+
+func (e *fasthttpEngineClient) Close() {}
+
+func newEngineClient(engine string, concurrentRequests int, counters *byteCounters) (engineClient, error) {
+	switch engine {
+	case "", "net":
+		return newNetEngineClient(concurrentRequests, counters), nil
+	case "fasthttp":
+		return newFasthttpEngineClient(concurrentRequests, counters), nil
+	default:
+		return nil, errors.New("unknown engine " + strconv.Quote(engine) + ", expected net or fasthttp")
+	}
+}
@@ -1,46 +1,19 @@
 package main
 
 import (
-	"errors"
+	"context"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"math"
-	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"golang.org/x/time/rate"
 )
-	
-func doRequest(client *http.Client,  url string, headers []header, method string, body string) (int, error) {
-	req, err := http.NewRequest(strings.ToUpper(method), url, strings.NewReader(body))
-	if err != nil {
-		log.Panic(err)
-	}
-	for _, h := range headers {
-		req.Header.Add(h.key, h.value)
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Panic(err)
-	}
-	io.Copy(io.Discard, resp.Body) //reads the entire body to EOF, clearing the TCP stream.
-	defer resp.Body.Close()
-	if (resp.StatusCode != http.StatusOK) {
-		return -1, errors.New(strconv.Itoa(resp.StatusCode))
-	}
-	if resp.Header["Content-Length"] == nil || len(resp.Header["Content-Length"]) == 0 {
-		return -1, errors.New("Wrong Content-Length header")
-	}
-	contentLength, err := strconv.Atoi(resp.Header["Content-Length"][0])
-	if err != nil || contentLength <= 0 {
-		return -1, errors.New("Wrong Content-Length header")
-	}
-	return contentLength, nil
-}
 
 func formatBytes(bytes int) string {
 	const unit = 1024
@@ -81,6 +54,12 @@ func main() {
     methodPtr := flag.String("method", "get", "request method")
 	flag.Var(&headerFlags, "header", "HTTP headers (K=V) to include in the request (can be specified multiple times)")
     bodyPtr := flag.String("data", "", "body to send in the request")
+    ratePtr := flag.Float64("rate", 0, "requests per second to offer, steady-state (0 = unbounded, send as fast as concurrent_requests allows)")
+    durationPtr := flag.Duration("duration", 0, "stop the run after this long, e.g. 30s (0 = unbounded, run until total_requests complete)")
+    enginePtr := flag.String("engine", "net", "HTTP engine to use: net or fasthttp")
+    scenarioPtr := flag.String("scenario", "", "path to a YAML/JSON scenario file of weighted requests (overrides the url argument and method/header/data flags)")
+    outPtr := flag.String("out", "", "write a full run report to this path (.json or .csv); empty disables it")
+    metricsAddrPtr := flag.String("metrics-addr", "", "serve Prometheus metrics at this address, e.g. :9090; empty disables it")
 
 	// Override the usage output
 	flag.Usage = func() {
@@ -91,68 +70,121 @@ func main() {
 
     flag.Parse()
 
-	if len(flag.Args()) < 1 {
-		flag.Usage()
-		os.Exit(1)
-	}
-
-	url := flag.Arg(0)
+	var url string
+	var sc *scenario
+	var vars *varStore
+	var stepReports map[string]*stepReport
 
     fmt.Println("concurrent_requests:", *concurrentRequestsPtr)
     fmt.Println("total_requests:", *totalRequestsPtr)
-    fmt.Println("url:", url)
-    fmt.Println("method:",*methodPtr)
-	if (len(headerFlags) > 0) {
-		fmt.Println("headers:")
-		for _, h := range headerFlags {
-			parts := strings.Split(h, "=")
-			if len(parts) != 2 {
-				fmt.Println("Invalid header format. Use K=V")
-				os.Exit(1)
+    fmt.Println("engine:", *enginePtr)
+
+	if *scenarioPtr != "" {
+		var err error
+		sc, err = loadScenario(*scenarioPtr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		vars = newVarStore()
+		stepReports = make(map[string]*stepReport, len(sc.Steps))
+		for _, step := range sc.Steps {
+			if _, ok := stepReports[step.label()]; !ok {
+				stepReports[step.label()] = newStepReport(step.label())
 			}
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			if key == "" || value == "" {
-				fmt.Println("Invalid header format. Use K=V")
-				os.Exit(1)
+		}
+		fmt.Println("scenario:", *scenarioPtr)
+		fmt.Println("requests in scenario:", len(sc.Steps))
+	} else {
+		if len(flag.Args()) < 1 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		url = flag.Arg(0)
+
+	    fmt.Println("url:", url)
+	    fmt.Println("method:",*methodPtr)
+		if (len(headerFlags) > 0) {
+			fmt.Println("headers:")
+			for _, h := range headerFlags {
+				parts := strings.Split(h, "=")
+				if len(parts) != 2 {
+					fmt.Println("Invalid header format. Use K=V")
+					os.Exit(1)
+				}
+				key := strings.TrimSpace(parts[0])
+				value := strings.TrimSpace(parts[1])
+				if key == "" || value == "" {
+					fmt.Println("Invalid header format. Use K=V")
+					os.Exit(1)
+				}
+				headers = append(headers, header{key: key, value: value})
+				fmt.Println(" -", h)
 			}
-			headers = append(headers, header{key: key, value: value})
-			fmt.Println(" -", h)
 		}
+		if (len(*bodyPtr) > 0) {
+			fmt.Println("body:", *bodyPtr)
+		}
+	}
+	if *ratePtr > 0 {
+		fmt.Println("rate:", *ratePtr, "req/s")
+	}
+	if *durationPtr > 0 {
+		fmt.Println("duration:", *durationPtr)
 	}
-	if (len(*bodyPtr) > 0) {
-		fmt.Println("body:", *bodyPtr)
+
+	counters := &byteCounters{}
+	engine, err := newEngineClient(*enginePtr, *concurrentRequestsPtr, counters)
+	if err != nil {
+		log.Fatal(err)
 	}
+	defer engine.Close()
+
+	var metrics *liveMetrics
+	if *metricsAddrPtr != "" {
+		metrics = newLiveMetrics()
+		metricsServer := startMetricsServer(*metricsAddrPtr)
+		defer metricsServer.Shutdown(context.Background())
+		fmt.Println("metrics-addr:", *metricsAddrPtr)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	var tr *http.Transport = &http.Transport{
-		MaxIdleConns:        100,
-		MaxConnsPerHost:     *concurrentRequestsPtr,
-		IdleConnTimeout:     60 * time.Second,
-		DisableKeepAlives:   false,
-		WriteBufferSize:     8 * 1024, // Reduce buffer size not to exhaust memory
-		ReadBufferSize:      8 * 1024, 
+	if *durationPtr > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *durationPtr)
+		defer cancel()
 	}
-	
-	var client *http.Client = &http.Client{Transport: tr}
-
-	type Result struct {
-		success bool
-		time time.Duration
-		contentLength  int
-		err error
+
+	var limiter *rate.Limiter
+	if *ratePtr > 0 {
+		limiter = rate.NewLimiter(rate.Limit(*ratePtr), 1)
 	}
-	
-	var success []Result
-	var failure []Result
 
-	results := make(chan Result, *totalRequestsPtr)
+	latency := newLatencyStats()
+	sizes := newSizeStats()
+	agg := &aggregator{}
+	series := &seriesCollector{}
+	errBreakdown := newErrorBreakdown()
+
 	sem := make(chan struct{}, *concurrentRequestsPtr)
 
 	var wg sync.WaitGroup
 
 	startTime := time.Now()
 
-	for range *totalRequestsPtr {
+	done := make(chan struct{})
+	progressDone := make(chan struct{})
+	go func() {
+		progressLoop(agg, counters, *totalRequestsPtr, startTime, latency.percentiles, series.add, done)
+		close(progressDone)
+	}()
+
+	for i := 0; i < *totalRequestsPtr; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+
 		wg.Add(1)
 		sem <- struct{}{}
 
@@ -160,12 +192,62 @@ func main() {
             defer wg.Done()
 			defer func() { <-sem }()
 
-			startTime := time.Now()
-			contentLength, err := doRequest(client, url, headers, *methodPtr, *bodyPtr)
+			if ctx.Err() != nil {
+				return
+			}
+			if limiter != nil {
+				if err := limiter.Wait(ctx); err != nil {
+					return
+				}
+			}
+
+			reqMethod, reqURL, reqBody, reqHeaders := *methodPtr, url, *bodyPtr, headers
+			var step scenarioStep
+			if sc != nil {
+				step = sc.pick()
+				reqMethod = step.Method
+				reqURL = vars.substitute(step.URL)
+				reqBody = vars.substitute(step.Body)
+				reqHeaders = make([]header, 0, len(step.Headers))
+				for k, v := range step.Headers {
+					reqHeaders = append(reqHeaders, header{key: k, value: vars.substitute(v)})
+				}
+			}
+
+			agg.requestStarted()
+			var stepRep *stepReport
+			if sc != nil {
+				stepRep = stepReports[step.label()]
+				stepRep.agg.requestStarted()
+			}
+
+			outcome, err := engine.Do(ctx, reqMethod, reqURL, reqHeaders, reqBody, sc != nil && len(step.Extract) > 0)
 			if err != nil {
-				results <- Result{success: false, err: err}
-			} else {
-				results <- Result{success: true, time: time.Since(startTime), contentLength: contentLength}
+				agg.requestFinished(false)
+				errBreakdown.record(err)
+				if metrics != nil {
+					metrics.recordRequest(0, err, 0, 0)
+				}
+				if stepRep != nil {
+					stepRep.agg.requestFinished(false)
+				}
+				return
+			}
+
+			agg.requestFinished(true)
+			latency.record(outcome.latency)
+			sizes.record(outcome.bodyBytes)
+			if metrics != nil {
+				metrics.recordRequest(outcome.status, nil, outcome.latency, outcome.bodyBytes)
+			}
+			if stepRep != nil {
+				stepRep.agg.requestFinished(true)
+				stepRep.latency.record(outcome.latency)
+				for varName, path := range step.Extract {
+					if val, ok := extractJSONField(outcome.body, path); ok {
+						vars.set(varName, val)
+					}
+				}
 			}
         }()
 	}
@@ -173,105 +255,100 @@ func main() {
 	wg.Wait()
 
 	workingTime := time.Since(startTime)
+	close(done)
+	<-progressDone
 
-	fmt.Println("All requests completed.")
-	close(results)
-
-	for res := range results {
-		if res.success {
-			success = append(success, res)
-		} else {
-			failure = append(failure, res)
-		}
+	if ctx.Err() != nil {
+		fmt.Println("Interrupted — reporting stats for requests completed so far.")
+	} else {
+		fmt.Println("All requests completed.")
 	}
 
+	completed, _, successes, errors := agg.snapshot()
+	failures := errors
+
 	// Statistics
-	fmt.Println("Total requests:", len(success)+len(failure))
-	fmt.Println("Successful requests:", len(success))
-	fmt.Println("Failed requests:", len(failure))
-	fmt.Println("Success rate:", float64(len(success))/float64(len(success)+len(failure))*100, "%")
-	fmt.Println("Failure rate:", float64(len(failure))/float64(len(success)+len(failure))*100, "%")
-	var totalTime time.Duration
-	for _, result := range success {
-		totalTime += result.time
-	}
-	fmt.Println("Average request time: ", func() time.Duration {
-		if len(success) == 0 {
-			return 0
-		}
-		return (totalTime / time.Duration(len(success)))
-	}(),
-	)
-	fmt.Println("Minimum request time:", func() time.Duration {
-		if len(success) == 0 {
-			return 0
-		}
-		min := time.Duration(1<<63 - 1) // = math.MaxInt64
-		for _, result := range success {
-			if result.time < min {
-				min = result.time
-			}
-		}
-		return min
-	}(),
-	)
-	fmt.Println("Maximum request time:", func() time.Duration {
-		if len(success) == 0 {
-			return 0
-		}
-		max := 0 * time.Second
-		for _, result := range success {
-			if result.time > max {
-				max = result.time
-			}
-		}
-		return max
-	}(),
-	)
+	fmt.Println("Total requests:", completed)
+	fmt.Println("Successful requests:", successes)
+	fmt.Println("Failed requests:", failures)
+	fmt.Println("Success rate:", float64(successes)/float64(completed)*100, "%")
+	fmt.Println("Failure rate:", float64(failures)/float64(completed)*100, "%")
+
+	latencyCount, meanLatency, minLatency, maxLatency := latency.snapshot()
+	fmt.Println("Average request time:", meanLatency)
+	fmt.Println("Request time stddev:", latency.stddev())
+	fmt.Println("Minimum request time:", minLatency)
+	fmt.Println("p50 request time:", latency.quantile(0.5))
+	fmt.Println("p90 request time:", latency.quantile(0.9))
+	fmt.Println("p95 request time:", latency.quantile(0.95))
+	fmt.Println("p99 request time:", latency.quantile(0.99))
+	fmt.Println("p999 request time:", latency.quantile(0.999))
+	fmt.Println("Maximum request time:", maxLatency)
 	fmt.Println("Total time:", workingTime)
 	fmt.Println("Requests per second:", func() float64 {
-		if workingTime == 0 {
+		if workingTime == 0 || latencyCount == 0 {
 			return 0
 		}
-		return math.Round(float64(len(success)) / workingTime.Seconds())
+		return float64(latencyCount) / workingTime.Seconds()
 	}(),
-	)	
-	var totalContentLength int
-	for _, result := range success {
-		totalContentLength += result.contentLength
-	}
-	fmt.Println("Average content length:", formatBytes(func() int {
-		if len(success) == 0 {
-			return 0
-		}
-		return totalContentLength / len(success)
-	}()),
 	)
-	fmt.Println("Minimum content length:", formatBytes(func() int {
-		if len(success) == 0 {
-			return 0
-		}
-		min := 1<<63 - 1
-		for _, result := range success {
-			if result.contentLength < min {
-				min = result.contentLength
+
+	_, avgSize, minSize, maxSize, totalSize := sizes.snapshot()
+	fmt.Println("Average content length:", formatBytes(avgSize))
+	fmt.Println("Minimum content length:", formatBytes(minSize))
+	fmt.Println("Maximum content length:", formatBytes(maxSize))
+	fmt.Println("Total content length:", formatBytes(totalSize))
+
+	bytesIn, bytesOut := counters.snapshot()
+	fmt.Println("Total bytes read from the wire:", formatBytes(int(bytesIn)))
+	fmt.Println("Total bytes written to the wire:", formatBytes(int(bytesOut)))
+
+	if sc != nil {
+		fmt.Println("\nPer-step stats:")
+		for _, step := range sc.Steps {
+			rep := stepReports[step.label()]
+			stepCompleted, _, stepSuccesses, stepErrors := rep.agg.snapshot()
+			if stepCompleted == 0 {
+				continue
 			}
+			_, stepMean, _, stepMax := rep.latency.snapshot()
+			fmt.Printf("  %s: completed=%d success=%d error=%d mean=%s p99=%s max=%s\n",
+				step.label(), stepCompleted, stepSuccesses, stepErrors, stepMean, rep.latency.quantile(0.99), stepMax)
 		}
-		return min
-	}()),
-	)
-	fmt.Println("Maximum content length:", formatBytes(func() int {
-		if len(success) == 0 {
-			return 0
+	}
+
+	if *outPtr != "" {
+		report := runReport{
+			Config: runConfig{
+				URL:                url,
+				Scenario:           *scenarioPtr,
+				Method:             *methodPtr,
+				ConcurrentRequests: *concurrentRequestsPtr,
+				TotalRequestsCap:   *totalRequestsPtr,
+				Rate:               *ratePtr,
+				Duration:           durationPtr.String(),
+				Engine:             *enginePtr,
+			},
+			TotalRequests: completed,
+			Successes:     successes,
+			Failures:      failures,
+			TotalTime:     workingTime,
+			MeanLatency:   meanLatency,
+			StddevLatency: latency.stddev(),
+			P50:           latency.quantile(0.5),
+			P90:           latency.quantile(0.9),
+			P95:           latency.quantile(0.95),
+			P99:           latency.quantile(0.99),
+			P999:          latency.quantile(0.999),
+			MaxLatency:    maxLatency,
+			Histogram:     latency.histogramSnapshot(),
+			ErrorsByCause: errBreakdown.snapshot(),
+			TimeSeries:    series.snapshot(),
 		}
-		max := 0
-		for _, result := range success {
-			if result.contentLength > max {
-				max = result.contentLength
-			}
+		if err := writeReport(*outPtr, report); err != nil {
+			fmt.Println("failed to write report:", err)
+		} else {
+			fmt.Println("report written to:", *outPtr)
 		}
-		return max
-	}()),
-	)
-	fmt.Println("Total content length:", formatBytes(totalContentLength))
-}
\ No newline at end of file
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// liveMetrics is the Prometheus collector set exposed at -metrics-addr,
+// mirroring the metric shape htping uses so existing dashboards can be
+// reused against this tool's runs.
+type liveMetrics struct {
+	requestsTotal  prometheus.Counter
+	responsesTotal *prometheus.CounterVec
+	duration       prometheus.Summary
+	responseSize   prometheus.Summary
+}
+
+func newLiveMetrics() *liveMetrics {
+	m := &liveMetrics{
+		requestsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "requests_total",
+			Help: "Total number of requests sent.",
+		}),
+		responsesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "responses_total",
+			Help: "Total number of responses received, by status code (or error class).",
+		}, []string{"code"}),
+		duration: prometheus.NewSummary(prometheus.SummaryOpts{
+			Name:       "duration_seconds",
+			Help:       "Request latency in seconds.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}),
+		responseSize: prometheus.NewSummary(prometheus.SummaryOpts{
+			Name: "response_size_bytes",
+			Help: "Response body size in bytes.",
+		}),
+	}
+	prometheus.MustRegister(m.requestsTotal, m.responsesTotal, m.duration, m.responseSize)
+	return m
+}
+
+func (m *liveMetrics) recordRequest(status int, err error, latency time.Duration, bodyBytes int) {
+	m.requestsTotal.Inc()
+	m.responsesTotal.WithLabelValues(metricsCodeLabel(status, err)).Inc()
+	m.duration.Observe(latency.Seconds())
+	m.responseSize.Observe(float64(bodyBytes))
+}
+
+// metricsCodeLabel always labels responses_total with the bare HTTP status
+// ("404"), on both the success and error paths, so PromQL like code=~"4.."
+// matches regardless of which path produced it; requests that never got a
+// status at all (network errors, timeouts) fall back to a non-numeric label
+// that can't collide with a real status code.
+func metricsCodeLabel(status int, err error) string {
+	if err == nil {
+		return strconv.Itoa(status)
+	}
+	if code, convErr := strconv.Atoi(err.Error()); convErr == nil {
+		return strconv.Itoa(code)
+	}
+	return "error"
+}
+
+// startMetricsServer serves /metrics in the background until the returned
+// server is shut down.
+func startMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Println("metrics server error:", err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// aggregator holds the counters workers update as requests complete, so the
+// live progress display can sample overall progress without touching
+// per-request state. Wire-level byte counts live in byteCounters instead,
+// since they're tallied by the engine's dialer rather than per request.
+type aggregator struct {
+	completed int64
+	inFlight  int64
+	successes int64
+	errors    int64
+}
+
+func (a *aggregator) requestStarted() {
+	atomic.AddInt64(&a.inFlight, 1)
+}
+
+func (a *aggregator) requestFinished(success bool) {
+	atomic.AddInt64(&a.inFlight, -1)
+	atomic.AddInt64(&a.completed, 1)
+	if success {
+		atomic.AddInt64(&a.successes, 1)
+	} else {
+		atomic.AddInt64(&a.errors, 1)
+	}
+}
+
+func (a *aggregator) snapshot() (completed, inFlight, successes, errors int64) {
+	return atomic.LoadInt64(&a.completed),
+		atomic.LoadInt64(&a.inFlight),
+		atomic.LoadInt64(&a.successes),
+		atomic.LoadInt64(&a.errors)
+}
+
+// liveDisplay repaints a fixed block of lines in place, uilive-style, by
+// moving the cursor back up to the start of the block before every redraw.
+type liveDisplay struct {
+	out       *os.File
+	lastLines int
+}
+
+func newLiveDisplay() *liveDisplay {
+	return &liveDisplay{out: os.Stdout}
+}
+
+func (d *liveDisplay) render(lines []string) {
+	if d.lastLines > 0 {
+		fmt.Fprintf(d.out, "\033[%dA", d.lastLines)
+	}
+	for _, line := range lines {
+		fmt.Fprint(d.out, "\033[2K", line, "\n")
+	}
+	d.lastLines = len(lines)
+}
+
+// progressLoop repaints the live stats block roughly once a second until
+// done is closed, then paints a final frame before returning. If sample is
+// non-nil it's called every tick too, so -out reports can keep a per-second
+// time series without a second ticker.
+func progressLoop(agg *aggregator, counters *byteCounters, total int, startTime time.Time, percentiles func() (p50, p90, p99 time.Duration), sample func(timeSeriesPoint), done <-chan struct{}) {
+	display := newLiveDisplay()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	lastCompleted := int64(0)
+	lastTick := startTime
+
+	paint := func() {
+		completed, inFlight, successes, errors := agg.snapshot()
+		bytesIn, bytesOut := counters.snapshot()
+		now := time.Now()
+
+		rps := 0.0
+		if elapsed := now.Sub(lastTick).Seconds(); elapsed > 0 {
+			rps = float64(completed-lastCompleted) / elapsed
+		}
+		lastCompleted = completed
+		lastTick = now
+
+		p50, p90, p99 := percentiles()
+		elapsedTotal := now.Sub(startTime).Seconds()
+
+		display.render([]string{
+			fmt.Sprintf("elapsed: %-10s rps: %-8.1f in-flight: %d", now.Sub(startTime).Round(time.Second), rps, inFlight),
+			fmt.Sprintf("completed: %d/%d  success: %d  error: %d", completed, total, successes, errors),
+			fmt.Sprintf("latency p50: %-8s p90: %-8s p99: %-8s", p50, p90, p99),
+			fmt.Sprintf("throughput in: %s/s  out: %s/s", formatBytes(bytesPerSecond(bytesIn, elapsedTotal)), formatBytes(bytesPerSecond(bytesOut, elapsedTotal))),
+		})
+
+		if sample != nil {
+			sample(timeSeriesPoint{ElapsedSeconds: elapsedTotal, RPS: rps, P50: p50, P99: p99, Errors: errors})
+		}
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			paint()
+		case <-done:
+			paint()
+			return
+		}
+	}
+}
+
+func bytesPerSecond(total int64, elapsedSeconds float64) int {
+	if elapsedSeconds <= 0 {
+		return 0
+	}
+	return int(float64(total) / elapsedSeconds)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeSeriesPoint is one sample of a run, taken by the same once-a-second
+// ticker that drives the live progress display, so -out reports can be
+// diffed across runs in CI instead of just comparing final totals. RPS
+// covers the interval since the previous sample; P50, P99 and Errors are
+// cumulative since the start of the run.
+type timeSeriesPoint struct {
+	ElapsedSeconds float64       `json:"elapsed_seconds"`
+	RPS            float64       `json:"rps"`
+	P50            time.Duration `json:"p50_ns"`
+	P99            time.Duration `json:"p99_ns"`
+	Errors         int64         `json:"errors"`
+}
+
+// seriesCollector buffers the time series behind a mutex since it's
+// appended to from the progress-loop goroutine and read from main once the
+// run finishes.
+type seriesCollector struct {
+	mu     sync.Mutex
+	points []timeSeriesPoint
+}
+
+func (s *seriesCollector) add(p timeSeriesPoint) {
+	s.mu.Lock()
+	s.points = append(s.points, p)
+	s.mu.Unlock()
+}
+
+func (s *seriesCollector) snapshot() []timeSeriesPoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]timeSeriesPoint, len(s.points))
+	copy(out, s.points)
+	return out
+}
+
+// errorBreakdown tallies failures by cause: the HTTP status code for
+// ordinary non-200 responses, or a coarse network error class for requests
+// that never got a status code at all.
+type errorBreakdown struct {
+	mu      sync.Mutex
+	byCause map[string]int64
+}
+
+func newErrorBreakdown() *errorBreakdown {
+	return &errorBreakdown{byCause: make(map[string]int64)}
+}
+
+func (e *errorBreakdown) record(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.byCause[classifyError(err)]++
+}
+
+func (e *errorBreakdown) snapshot() map[string]int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make(map[string]int64, len(e.byCause))
+	for k, v := range e.byCause {
+		out[k] = v
+	}
+	return out
+}
+
+// classifyError turns a request error into "status <code>" for the usual
+// case (each engineClient's Do returns the status code as the error for any
+// non-200 response), or a coarse class for errors that never reached a
+// response.
+func classifyError(err error) string {
+	if err == nil {
+		return "none"
+	}
+	if code, convErr := strconv.Atoi(err.Error()); convErr == nil {
+		return "status " + strconv.Itoa(code)
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "network_error"
+	}
+}
+
+// runConfig records the flags a run was made with, so -out reports are
+// self-describing when diffed later.
+type runConfig struct {
+	URL                string  `json:"url,omitempty"`
+	Scenario           string  `json:"scenario,omitempty"`
+	Method             string  `json:"method,omitempty"`
+	ConcurrentRequests int     `json:"concurrent_requests"`
+	TotalRequestsCap   int     `json:"total_requests_cap"`
+	Rate               float64 `json:"rate,omitempty"`
+	Duration           string  `json:"duration,omitempty"`
+	Engine             string  `json:"engine"`
+}
+
+// runReport is the full -out payload: config, final stats, the latency
+// histogram, an error breakdown, and the per-second time series.
+type runReport struct {
+	Config        runConfig         `json:"config"`
+	TotalRequests int64             `json:"total_requests"`
+	Successes     int64             `json:"successes"`
+	Failures      int64             `json:"failures"`
+	TotalTime     time.Duration     `json:"total_time_ns"`
+	MeanLatency   time.Duration     `json:"mean_latency_ns"`
+	StddevLatency time.Duration     `json:"stddev_latency_ns"`
+	P50           time.Duration     `json:"p50_ns"`
+	P90           time.Duration     `json:"p90_ns"`
+	P95           time.Duration     `json:"p95_ns"`
+	P99           time.Duration     `json:"p99_ns"`
+	P999          time.Duration     `json:"p999_ns"`
+	MaxLatency    time.Duration     `json:"max_latency_ns"`
+	Histogram     []bucketSnapshot  `json:"histogram"`
+	ErrorsByCause map[string]int64  `json:"errors_by_cause"`
+	TimeSeries    []timeSeriesPoint `json:"time_series"`
+}
+
+func writeReport(path string, report runReport) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return writeJSONReport(path, report)
+	case ".csv":
+		return writeCSVReport(path, report)
+	default:
+		return fmt.Errorf("unsupported report extension %q, expected .json or .csv", filepath.Ext(path))
+	}
+}
+
+func writeJSONReport(path string, report runReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// writeCSVReport writes the same content as the JSON report, as a sequence
+// of labeled sections (config, summary, histogram, errors, time series)
+// separated by blank lines, since CSV has no native way to nest tables of
+// different shapes in one file.
+func writeCSVReport(path string, report runReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	defer w.Flush()
+
+	if err := writeCSVSection(w, "config", []string{"field", "value"}, configRows(report.Config)); err != nil {
+		return err
+	}
+	if err := writeCSVSection(w, "summary", []string{"field", "value"}, summaryRows(report)); err != nil {
+		return err
+	}
+	if err := writeCSVSection(w, "histogram", []string{"upper_bound_ns", "count"}, histogramRows(report.Histogram)); err != nil {
+		return err
+	}
+	if err := writeCSVSection(w, "errors_by_cause", []string{"cause", "count"}, errorRows(report.ErrorsByCause)); err != nil {
+		return err
+	}
+	if err := writeCSVSection(w, "time_series", []string{"elapsed_seconds", "rps", "p50_ns", "p99_ns", "errors"}, timeSeriesRows(report.TimeSeries)); err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+// writeCSVSection writes one "# title" marker row, a header row, the data
+// rows, then a blank separator row, so a reader can split the file back
+// into sections on blank lines (or skip "#"-prefixed rows entirely).
+func writeCSVSection(w *csv.Writer, title string, header []string, rows [][]string) error {
+	if err := w.Write([]string{"# " + title}); err != nil {
+		return err
+	}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	return w.Write([]string{})
+}
+
+func configRows(c runConfig) [][]string {
+	return [][]string{
+		{"url", c.URL},
+		{"scenario", c.Scenario},
+		{"method", c.Method},
+		{"concurrent_requests", strconv.Itoa(c.ConcurrentRequests)},
+		{"total_requests_cap", strconv.Itoa(c.TotalRequestsCap)},
+		{"rate", strconv.FormatFloat(c.Rate, 'f', -1, 64)},
+		{"duration", c.Duration},
+		{"engine", c.Engine},
+	}
+}
+
+func summaryRows(r runReport) [][]string {
+	return [][]string{
+		{"total_requests", strconv.FormatInt(r.TotalRequests, 10)},
+		{"successes", strconv.FormatInt(r.Successes, 10)},
+		{"failures", strconv.FormatInt(r.Failures, 10)},
+		{"total_time_ns", strconv.FormatInt(int64(r.TotalTime), 10)},
+		{"mean_latency_ns", strconv.FormatInt(int64(r.MeanLatency), 10)},
+		{"stddev_latency_ns", strconv.FormatInt(int64(r.StddevLatency), 10)},
+		{"p50_ns", strconv.FormatInt(int64(r.P50), 10)},
+		{"p90_ns", strconv.FormatInt(int64(r.P90), 10)},
+		{"p95_ns", strconv.FormatInt(int64(r.P95), 10)},
+		{"p99_ns", strconv.FormatInt(int64(r.P99), 10)},
+		{"p999_ns", strconv.FormatInt(int64(r.P999), 10)},
+		{"max_latency_ns", strconv.FormatInt(int64(r.MaxLatency), 10)},
+	}
+}
+
+func histogramRows(buckets []bucketSnapshot) [][]string {
+	rows := make([][]string, 0, len(buckets))
+	for _, b := range buckets {
+		rows = append(rows, []string{strconv.FormatInt(b.UpperBoundNanos, 10), strconv.FormatInt(b.Count, 10)})
+	}
+	return rows
+}
+
+// errorRows sorts by cause so the output (and any diff of it) is
+// deterministic despite ErrorsByCause being a map.
+func errorRows(byCause map[string]int64) [][]string {
+	causes := make([]string, 0, len(byCause))
+	for cause := range byCause {
+		causes = append(causes, cause)
+	}
+	sort.Strings(causes)
+
+	rows := make([][]string, 0, len(causes))
+	for _, cause := range causes {
+		rows = append(rows, []string{cause, strconv.FormatInt(byCause[cause], 10)})
+	}
+	return rows
+}
+
+func timeSeriesRows(points []timeSeriesPoint) [][]string {
+	rows := make([][]string, 0, len(points))
+	for _, point := range points {
+		rows = append(rows, []string{
+			strconv.FormatFloat(point.ElapsedSeconds, 'f', -1, 64),
+			strconv.FormatFloat(point.RPS, 'f', -1, 64),
+			strconv.FormatInt(int64(point.P50), 10),
+			strconv.FormatInt(int64(point.P99), 10),
+			strconv.FormatInt(point.Errors, 10),
+		})
+	}
+	return rows
+}
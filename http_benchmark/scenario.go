@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// scenarioStep is one entry in a -scenario file: a single request
+// definition, optionally weighted for random selection, that can extract
+// values from its response body for later steps to reuse via {{var}}
+// placeholders in their own url/headers/body.
+type scenarioStep struct {
+	Name     string            `yaml:"name" json:"name"`
+	Method   string            `yaml:"method" json:"method"`
+	URL      string            `yaml:"url" json:"url"`
+	Headers  map[string]string `yaml:"headers" json:"headers"`
+	Body     string            `yaml:"body" json:"body"`
+	BodyFile string            `yaml:"body_file" json:"body_file"`
+	Weight   float64           `yaml:"weight" json:"weight"`
+	Extract  map[string]string `yaml:"extract" json:"extract"` // var name -> dot-path into the JSON response body
+}
+
+// label identifies a step in per-step stats and error messages, falling
+// back to method+url when the scenario file doesn't name the step.
+func (s scenarioStep) label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return strings.ToUpper(s.Method) + " " + s.URL
+}
+
+type scenario struct {
+	Steps []scenarioStep `yaml:"requests" json:"requests"`
+}
+
+func loadScenario(path string) (*scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s scenario
+	if strings.HasSuffix(path, ".json") {
+		err = json.Unmarshal(data, &s)
+	} else {
+		err = yaml.Unmarshal(data, &s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Steps) == 0 {
+		return nil, errors.New("scenario file defines no requests")
+	}
+
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		if step.BodyFile != "" {
+			body, err := os.ReadFile(step.BodyFile)
+			if err != nil {
+				return nil, err
+			}
+			step.Body = string(body)
+		}
+		if step.Method == "" {
+			step.Method = "get"
+		}
+		if step.Weight <= 0 {
+			step.Weight = 1
+		}
+	}
+
+	return &s, nil
+}
+
+// pick does a weighted random selection over the scenario's steps.
+func (s *scenario) pick() scenarioStep {
+	total := 0.0
+	for _, step := range s.Steps {
+		total += step.Weight
+	}
+	r := rand.Float64() * total
+	for _, step := range s.Steps {
+		if r < step.Weight {
+			return step
+		}
+		r -= step.Weight
+	}
+	return s.Steps[len(s.Steps)-1]
+}
+
+// varStore holds the values scenario steps extract from responses, so later
+// steps can substitute them into their own url/headers/body.
+type varStore struct {
+	mu   sync.RWMutex
+	vars map[string]string
+}
+
+func newVarStore() *varStore {
+	return &varStore{vars: make(map[string]string)}
+}
+
+func (v *varStore) set(name, value string) {
+	v.mu.Lock()
+	v.vars[name] = value
+	v.mu.Unlock()
+}
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// substitute replaces every {{var}} placeholder in s with the current value
+// of var, leaving unknown placeholders untouched.
+func (v *varStore) substitute(s string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return placeholderPattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := placeholderPattern.FindStringSubmatch(m)[1]
+		if val, ok := v.vars[name]; ok {
+			return val
+		}
+		return m
+	})
+}
+
+// extractJSONField reads a dot-path (e.g. "token" or "data.id") out of a
+// JSON response body.
+func extractJSONField(body []byte, path string) (string, bool) {
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "", false
+	}
+	for _, part := range strings.Split(path, ".") {
+		m, ok := data.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		data, ok = m[part]
+		if !ok {
+			return "", false
+		}
+	}
+	switch v := data.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case nil:
+		return "", false
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	}
+}
+
+// stepReport holds per-step stats, mirroring the global request counters and
+// latency stats but scoped to a single scenario step so a run can show where
+// time is actually going across a multi-endpoint workload. Response sizes are
+// not tracked per step.
+type stepReport struct {
+	label   string
+	agg     *aggregator
+	latency *latencyStats
+}
+
+func newStepReport(label string) *stepReport {
+	return &stepReport{label: label, agg: &aggregator{}, latency: newLatencyStats()}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Logarithmic latency buckets from 1µs to 60s at ~5% relative error,
+// HDR-histogram style, so quantiles can be reconstructed without keeping
+// every sample in memory.
+const (
+	histMinNanos = float64(time.Microsecond)
+	histMaxNanos = float64(60 * time.Second)
+	histGrowth   = 1.05
+)
+
+var histBucketCount = int(math.Ceil(math.Log(histMaxNanos/histMinNanos)/math.Log(histGrowth))) + 2
+
+func histBucket(d time.Duration) int {
+	nanos := float64(d)
+	if nanos <= histMinNanos {
+		return 0
+	}
+	if nanos >= histMaxNanos {
+		return histBucketCount - 1
+	}
+	return 1 + int(math.Log(nanos/histMinNanos)/math.Log(histGrowth))
+}
+
+func histBucketValue(i int) time.Duration {
+	if i <= 0 {
+		return time.Duration(histMinNanos)
+	}
+	return time.Duration(histMinNanos * math.Pow(histGrowth, float64(i-1)))
+}
+
+// latencyStats tracks latency in constant memory regardless of request
+// count: a running mean/variance via Welford's online algorithm, plus a
+// log-bucket histogram for approximate quantiles.
+type latencyStats struct {
+	mu    sync.Mutex
+	count int64
+	mean  float64 // nanoseconds
+	m2    float64 // sum of squared deviations from the running mean
+	min   time.Duration
+	max   time.Duration
+	hist  []int64
+}
+
+func newLatencyStats() *latencyStats {
+	return &latencyStats{hist: make([]int64, histBucketCount)}
+}
+
+func (s *latencyStats) record(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.count++
+	delta := float64(d) - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (float64(d) - s.mean)
+
+	if s.count == 1 || d < s.min {
+		s.min = d
+	}
+	if d > s.max {
+		s.max = d
+	}
+	s.hist[histBucket(d)]++
+}
+
+func (s *latencyStats) stddev() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count < 2 {
+		return 0
+	}
+	return time.Duration(math.Sqrt(s.m2 / float64(s.count-1)))
+}
+
+// quantile returns an approximate p-th quantile (0 <= p <= 1) by walking the
+// histogram buckets, so it costs O(buckets) rather than O(n log n).
+func (s *latencyStats) quantile(p float64) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(p * float64(s.count)))
+	var cum int64
+	for i, c := range s.hist {
+		cum += c
+		if cum >= target {
+			return histBucketValue(i)
+		}
+	}
+	return s.max
+}
+
+// percentiles returns p50/p90/p99 in one call, for the live progress display.
+func (s *latencyStats) percentiles() (p50, p90, p99 time.Duration) {
+	return s.quantile(0.5), s.quantile(0.9), s.quantile(0.99)
+}
+
+func (s *latencyStats) snapshot() (count int64, mean time.Duration, min, max time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count, time.Duration(s.mean), s.min, s.max
+}
+
+// bucketSnapshot is one non-empty row of the latency histogram, for -out
+// reports: the bucket's upper bound and how many samples fell in it.
+type bucketSnapshot struct {
+	UpperBoundNanos int64 `json:"upper_bound_ns"`
+	Count           int64 `json:"count"`
+}
+
+func (s *latencyStats) histogramSnapshot() []bucketSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]bucketSnapshot, 0, len(s.hist))
+	for i, c := range s.hist {
+		if c == 0 {
+			continue
+		}
+		out = append(out, bucketSnapshot{UpperBoundNanos: int64(histBucketValue(i + 1)), Count: c})
+	}
+	return out
+}
+
+// sizeStats tracks content-length stats (count/avg/min/max) in constant
+// memory, the same streaming shape as latencyStats but without a histogram
+// since only the extremes and the mean are reported.
+type sizeStats struct {
+	mu    sync.Mutex
+	count int64
+	sum   int64
+	min   int
+	max   int
+}
+
+func newSizeStats() *sizeStats {
+	return &sizeStats{}
+}
+
+func (s *sizeStats) record(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count++
+	s.sum += int64(n)
+	if s.count == 1 || n < s.min {
+		s.min = n
+	}
+	if n > s.max {
+		s.max = n
+	}
+}
+
+func (s *sizeStats) snapshot() (count int64, avg, min, max, total int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count == 0 {
+		return 0, 0, 0, 0, 0
+	}
+	return s.count, int(s.sum / s.count), s.min, s.max, int(s.sum)
+}